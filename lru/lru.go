@@ -17,7 +17,11 @@ limitations under the License.
 // Package lru implements an LRU cache.
 package lru
 
-import "container/list"
+import (
+	"container/list"
+	"sort"
+	"time"
+)
 
 // Cache is an LRU cache. It is not safe for concurrent access.
 // groupcache的核心数据结构
@@ -25,15 +29,49 @@ type Cache struct {
 	// MaxEntries is the maximum number of cache entries before
 	// an item is evicted. Zero means no limit.
 
-	MaxEntries int 	// maxBytes 是允许使用的最大内存
+	MaxEntries int // maxBytes 是允许使用的最大内存
+
+	// MaxBytes is the maximum total cost of cache entries, as measured by
+	// CostFn, before an item is evicted. Zero means no byte-size limit.
+	// MaxEntries and MaxBytes can be used together: an Add evicts from the
+	// back until both limits are satisfied.
+	// MaxBytes 是允许使用的最大内存（由 CostFn 计算得出），为 0 表示不限制。
+	// MaxEntries 和 MaxBytes 可以同时生效，Add 时会持续从队首淘汰直到两者都满足。
+	MaxBytes int64
+
+	// CostFn optionally computes the cost (typically, but not necessarily,
+	// a byte size) of a cache entry. It is consulted by Add to maintain
+	// nbytes against MaxBytes. If nil, entries are considered to have zero
+	// cost and MaxBytes has no effect.
+	// CostFn 用于计算一个缓存项的开销（通常是字节数），Add 时用它维护 nbytes，
+	// 并据此与 MaxBytes 比较做淘汰。为 nil 时所有条目开销视为 0，MaxBytes 不生效。
+	CostFn func(key Key, value interface{}) int64
 
 	// OnEvicted optionally specifies a callback function to be
 	// executed when an entry is purged from the cache.
 	OnEvicted func(key Key, value interface{}) //提供一个淘汰值时的钩子函数
 
-	
-	ll    *list.List // 用于实现LRU的双向链表
-	cache map[interface{}]*list.Element // 键是空接口，值是双向链表中对应节点的指针。
+	// OnExpired optionally specifies a callback function to be executed
+	// when an entry is removed because it expired (see AddWithTTL), as
+	// opposed to being evicted for capacity. If nil, OnEvicted fires for
+	// expired entries too.
+	// OnExpired 可选地指定一个因过期（见 AddWithTTL）而被移除时触发的回调，
+	// 与因容量不足被淘汰加以区分。为 nil 时过期条目也会触发 OnEvicted。
+	OnExpired func(key Key, value interface{})
+
+	ll     *list.List                    // 用于实现LRU的双向链表
+	cache  map[interface{}]*list.Element // 键是空接口，值是双向链表中对应节点的指针。
+	nbytes int64                         // 当前缓存中所有条目的开销总和，由 CostFn 计算
+
+	// sampled, sampleSize, clock and pool implement an approximated-LRU
+	// eviction mode (see NewSampled) that evicts by sampling instead of
+	// exact recency via ll. sampled 为 true 时启用近似 LRU（采样淘汰），
+	// Get/Add 不再做 MoveToFront，而是更新 entry 的访问时钟，淘汰时从 map
+	// 中随机采样，避免高并发下对双向链表的频繁改写造成的 cache-line 抖动。
+	sampled    bool
+	sampleSize int
+	clock      uint32          // 单调递增的 24 位访问时钟，每次 Get/Add 递增，会回绕
+	pool       []*list.Element // 淘汰候选池，按距今的空闲时长降序排列（池首最旧）
 }
 
 // A Key may be any value that is comparable. See http://golang.org/ref/spec#Comparison_operators
@@ -43,8 +81,27 @@ type Key interface{} //只要是可以用来作为比较的对象，均可以作
 type entry struct {
 	key   Key
 	value interface{}
+	size  int64  // 该条目的开销，由 CostFn 计算得出，CostFn 为 nil 时恒为 0
+	clock uint32 // 近似 LRU 模式下的最近访问时钟，其他模式下不使用
+
+	expiresAt time.Time // 过期时间，零值表示永不过期，由 AddWithTTL 设置
 }
 
+// expired reports whether e has a TTL and it has passed.
+func (e *entry) expired() bool {
+	return !e.expiresAt.IsZero() && time.Now().After(e.expiresAt)
+}
+
+// defaultSampleSize is the number of candidates NewSampled examines per
+// eviction when the caller doesn't specify one, matching Redis's default
+// maxmemory-samples.
+const defaultSampleSize = 5
+
+// evictionPoolSize bounds the eviction candidate pool maintained across
+// sampling rounds in approximated-LRU mode, so a few unlucky samples don't
+// immediately evict something nearly as fresh as the true LRU victim.
+const evictionPoolSize = 16
+
 // New creates a new Cache.
 // If maxEntries is zero, the cache has no limit and it's assumed
 // that eviction is done by the caller.
@@ -57,27 +114,221 @@ func New(maxEntries int) *Cache {
 	}
 }
 
+// NewWithSize creates a new Cache bounded by total entry cost rather than
+// entry count. costFn computes the cost of each key/value pair; Add evicts
+// from the back until the running total tracked in nbytes is at most
+// maxBytes. If maxBytes is zero, the cache has no byte-size limit and
+// behaves like New with eviction left to the caller (or to MaxEntries).
+// NewWithSize 创建一个按总开销（而非条目数）限制大小的 Cache。costFn 用来计算
+// 每个键值对的开销，Add 时会持续从队首淘汰直到 nbytes 不超过 maxBytes。
+// maxBytes 为 0 时不限制字节开销，淘汰交给调用方（或 MaxEntries）处理。
+func NewWithSize(maxBytes int64, costFn func(key Key, value interface{}) int64) *Cache {
+	return &Cache{
+		MaxBytes: maxBytes,
+		CostFn:   costFn,
+		ll:       list.New(),
+		cache:    make(map[interface{}]*list.Element),
+	}
+}
+
+// NewSampled creates a new Cache using an approximated-LRU eviction policy,
+// Redis-style: instead of splicing a doubly-linked list on every Get, each
+// entry carries a 24-bit access clock, and eviction samples sampleSize
+// random entries and evicts the one with the oldest clock. If sampleSize is
+// zero or negative, defaultSampleSize is used. This trades strict recency
+// for O(1) Get with no list maintenance, which matters under the mutex
+// groupcache's hot cache takes on every access.
+// NewSampled 创建一个使用近似 LRU（采样淘汰）策略的 Cache，仿照 Redis 的实现：
+// 不再在每次 Get 时改写双向链表，而是给每个条目维护一个 24 位访问时钟，淘汰时
+// 从 map 中采样 sampleSize 个候选，淘汰其中时钟最旧的一个。sampleSize 为 0 或
+// 负数时使用 defaultSampleSize。以牺牲严格的访问顺序精度为代价换取 O(1) 的
+// Get，在 groupcache 热点缓存这种每次访问都持有互斥锁的路径上更划算。
+func NewSampled(maxEntries int, sampleSize int) *Cache {
+	if sampleSize <= 0 {
+		sampleSize = defaultSampleSize
+	}
+	return &Cache{
+		MaxEntries: maxEntries,
+		sampled:    true,
+		sampleSize: sampleSize,
+		ll:         list.New(),
+		cache:      make(map[interface{}]*list.Element),
+	}
+}
+
 // Add adds a value to the cache.
 //如果键存在，则更新对应节点的值，并将该节点移到队尾。
 // 不存在则是新增场景，首先队尾添加新节点 &entry{key, value}, 并字典中添加 key 和节点的映射关系。
 // 更新 c.nbytes，如果超过了设定的最大值 c.maxBytes，则移除最少访问的节点。
 func (c *Cache) Add(key Key, value interface{}) {
+	c.add(key, value, time.Time{})
+}
+
+// AddWithTTL is like Add but the entry expires after ttl: once ttl has
+// passed, Get stops returning it (removing it lazily and firing OnExpired,
+// or OnEvicted if OnExpired is unset) even though it hasn't been evicted
+// for capacity. Expiration is checked lazily, on Get, rather than by a
+// background sweep: Cache is documented as unsafe for concurrent access,
+// and a goroutine walking the list on a timer would race with any other
+// goroutine calling Add/Get/Remove, with no lock for callers to take to
+// prevent it.
+// AddWithTTL 与 Add 类似，但条目会在 ttl 后过期：一旦过期，Get 就不会再返回它
+// （并惰性地移除它，触发 OnExpired，若未设置则触发 OnEvicted），即使它还没有
+// 因为容量不足被淘汰。过期检查是惰性的，在 Get 时才发生，而不是靠后台协程
+// 定时扫描：Cache 本身并不是并发安全的，一个按计时器遍历链表的协程会和任何
+// 同时调用 Add/Get/Remove 的协程产生数据竞争，而调用方又没有锁可以阻止它。
+func (c *Cache) AddWithTTL(key Key, value interface{}, ttl time.Duration) {
+	c.add(key, value, time.Now().Add(ttl))
+}
+
+func (c *Cache) add(key Key, value interface{}, expiresAt time.Time) {
 	if c.cache == nil {
 		c.cache = make(map[interface{}]*list.Element)
 		c.ll = list.New()
 	}
+	size := c.costOf(key, value)
 	if ee, ok := c.cache[key]; ok {
-		c.ll.MoveToFront(ee)
-		ee.Value.(*entry).value = value
+		kv := ee.Value.(*entry)
+		c.nbytes += size - kv.size
+		kv.value = value
+		kv.size = size
+		kv.expiresAt = expiresAt
+		if c.sampled {
+			kv.clock = c.tick()
+			c.poolRemove(ee) // touched again: its old pool position is now stale
+		} else {
+			c.ll.MoveToFront(ee)
+		}
+		c.evict()
 		return
 	}
-	ele := c.ll.PushFront(&entry{key, value})
+	ele := c.ll.PushFront(&entry{key: key, value: value, size: size, expiresAt: expiresAt})
+	if c.sampled {
+		ele.Value.(*entry).clock = c.tick()
+	}
 	c.cache[key] = ele
-	if c.MaxEntries != 0 && c.ll.Len() > c.MaxEntries {
-		c.RemoveOldest()
+	c.nbytes += size
+	c.evict()
+}
+
+// tick advances the approximated-LRU access clock and returns its new,
+// 24-bit-wrapped value.
+func (c *Cache) tick() uint32 {
+	c.clock = (c.clock + 1) & 0xFFFFFF
+	return c.clock
+}
+
+// idleSince returns how long ago (in clock ticks) clock was last touched,
+// relative to the current clock. Computed as a modular difference, exactly
+// like Redis's `(now - obj->lru) & LRU_CLOCK_MAX`, so that wraparound of the
+// 24-bit clock doesn't make a just-touched entry (clock wrapped to a small
+// value) look older than an untouched one sitting on the high side of the
+// wrap.
+func (c *Cache) idleSince(clock uint32) uint32 {
+	return (c.clock - clock) & 0xFFFFFF
+}
+
+// evict removes items from the back of the list (in approximated-LRU mode,
+// the sampled victim instead) until both MaxEntries and MaxBytes (whichever
+// are set) are satisfied.
+// evict 持续移除条目，直到 MaxEntries 与 MaxBytes（若设置了）都被满足；
+// 近似 LRU 模式下淘汰的是采样出的最旧条目，而不是严格的队尾条目。
+func (c *Cache) evict() {
+	for c.MaxEntries != 0 && c.ll.Len() > c.MaxEntries {
+		c.removeVictim()
+	}
+	for c.MaxBytes != 0 && c.nbytes > c.MaxBytes && c.ll.Len() > 0 {
+		c.removeVictim()
 	}
 }
 
+// removeVictim removes one entry, chosen by the cache's eviction policy.
+func (c *Cache) removeVictim() {
+	if c.sampled {
+		if ele := c.sampleVictim(); ele != nil {
+			c.removeElement(ele, false)
+		}
+		return
+	}
+	c.RemoveOldest()
+}
+
+// sampleVictim samples sampleSize random entries (Go's randomized map
+// iteration order gives us this for free, with no extra RNG needed), folds
+// them into the eviction pool, and returns the oldest pool member.
+// sampleVictim 采样 sampleSize 个随机条目（借助 Go map 遍历起点随机化的特性，
+// 不需要额外的随机数生成器），将它们并入淘汰候选池，返回池中最旧的一个。
+func (c *Cache) sampleVictim() *list.Element {
+	if len(c.cache) == 0 {
+		return nil
+	}
+	n := c.sampleSize
+	if n <= 0 {
+		n = defaultSampleSize
+	}
+	sampled := 0
+	for _, ele := range c.cache {
+		c.poolInsert(ele)
+		sampled++
+		if sampled >= n {
+			break
+		}
+	}
+	if len(c.pool) == 0 {
+		return nil
+	}
+	victim := c.pool[0]
+	c.pool = c.pool[1:]
+	return victim
+}
+
+// poolInsert folds a newly-sampled candidate into the eviction pool,
+// keeping it sorted oldest-first (by idle time, not raw clock, so a clock
+// wraparound can't make a fresh entry look ancient) and capped at
+// evictionPoolSize: once full, a candidate only displaces the pool's
+// current youngest member, and only if it is itself older.
+func (c *Cache) poolInsert(ele *list.Element) {
+	for _, p := range c.pool {
+		if p == ele {
+			return // already a candidate
+		}
+	}
+	if len(c.pool) < evictionPoolSize {
+		c.pool = append(c.pool, ele)
+	} else {
+		youngest := c.pool[len(c.pool)-1]
+		if c.idleSince(ele.Value.(*entry).clock) <= c.idleSince(youngest.Value.(*entry).clock) {
+			return
+		}
+		c.pool[len(c.pool)-1] = ele
+	}
+	sort.Slice(c.pool, func(i, j int) bool {
+		return c.idleSince(c.pool[i].Value.(*entry).clock) > c.idleSince(c.pool[j].Value.(*entry).clock)
+	})
+}
+
+// poolRemove drops e from the eviction pool, if present: because it was
+// just evicted or removed, or because its clock was just bumped by a fresh
+// Get/Add and its old, stale pool position would otherwise make a
+// recently-touched entry look like the oldest thing in the cache.
+func (c *Cache) poolRemove(e *list.Element) {
+	for i, p := range c.pool {
+		if p == e {
+			c.pool = append(c.pool[:i], c.pool[i+1:]...)
+			return
+		}
+	}
+}
+
+// costOf returns the cost of a key/value pair as reported by CostFn, or
+// zero if CostFn is unset.
+func (c *Cache) costOf(key Key, value interface{}) int64 {
+	if c.CostFn == nil {
+		return 0
+	}
+	return c.CostFn(key, value)
+}
+
 // Get looks up a key's value from the cache.
 // 第一步是从字典中找到对应的双向链表的节点，第二步，将该节点移动到队尾
 // 如果键对应的链表节点存在，则将对应节点移动到队尾，并返回查找到的值。
@@ -87,8 +338,18 @@ func (c *Cache) Get(key Key) (value interface{}, ok bool) {
 		return
 	}
 	if ele, hit := c.cache[key]; hit {
-		c.ll.MoveToFront(ele)
-		return ele.Value.(*entry).value, true
+		kv := ele.Value.(*entry)
+		if kv.expired() {
+			c.removeElement(ele, true)
+			return
+		}
+		if c.sampled {
+			kv.clock = c.tick()
+			c.poolRemove(ele) // touched again: its old pool position is now stale
+		} else {
+			c.ll.MoveToFront(ele)
+		}
+		return kv.value, true
 	}
 	return
 }
@@ -99,7 +360,7 @@ func (c *Cache) Remove(key Key) {
 		return
 	}
 	if ele, hit := c.cache[key]; hit {
-		c.removeElement(ele)
+		c.removeElement(ele, false)
 	}
 }
 
@@ -110,15 +371,24 @@ func (c *Cache) RemoveOldest() {
 	}
 	ele := c.ll.Back()
 	if ele != nil {
-		c.removeElement(ele)
+		c.removeElement(ele, false)
 	}
 }
 
-func (c *Cache) removeElement(e *list.Element) {
+// removeElement unlinks e from the cache. expired distinguishes a lazy TTL
+// removal (see AddWithTTL) from a capacity eviction, so the right callback
+// (OnExpired vs OnEvicted) fires.
+func (c *Cache) removeElement(e *list.Element, expired bool) {
 	c.ll.Remove(e)
 	kv := e.Value.(*entry)
 	delete(c.cache, kv.key)
-	if c.OnEvicted != nil {
+	c.nbytes -= kv.size
+	if c.sampled {
+		c.poolRemove(e)
+	}
+	if expired && c.OnExpired != nil {
+		c.OnExpired(kv.key, kv.value)
+	} else if c.OnEvicted != nil {
 		c.OnEvicted(kv.key, kv.value)
 	}
 }
@@ -131,6 +401,13 @@ func (c *Cache) Len() int {
 	return c.ll.Len()
 }
 
+// Bytes returns the total cost of items currently in the cache, as
+// computed by CostFn. It is zero if CostFn was never set.
+// Bytes 返回当前缓存中所有条目的开销总和（由 CostFn 计算），若从未设置 CostFn 则恒为 0。
+func (c *Cache) Bytes() int64 {
+	return c.nbytes
+}
+
 // Clear purges all stored items from the cache.
 func (c *Cache) Clear() {
 	if c.OnEvicted != nil {
@@ -141,4 +418,6 @@ func (c *Cache) Clear() {
 	}
 	c.ll = nil
 	c.cache = nil
+	c.nbytes = 0
+	c.pool = nil
 }
@@ -0,0 +1,172 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"testing"
+	"time"
+)
+
+// TestSampledDoesNotEvictRecentlyTouchedEntry guards against the eviction
+// pool going stale: once an entry lands in the pool, touching it again (via
+// Get) bumps its clock, and it must not keep sitting in the pool at its old,
+// now-stale position where it could be evicted ahead of genuinely idle
+// entries.
+func TestSampledDoesNotEvictRecentlyTouchedEntry(t *testing.T) {
+	// sampleSize equals the number of entries added, so every sampling round covers the whole
+	// cache: this makes the test deterministic rather than depending on
+	// which random subset gets sampled. MaxEntries is set high enough that
+	// populating the cache below doesn't itself trigger an eviction.
+	c := NewSampled(10, 5)
+	for i := 0; i < 5; i++ {
+		c.Add(i, i) // clocks 1..5, in insertion order
+	}
+
+	// Seed the eviction pool with all 5 entries at their current clocks,
+	// exactly as a prior eviction round would have.
+	for _, ele := range c.cache {
+		c.poolInsert(ele)
+	}
+
+	// Key 2 becomes, by far, the freshest entry in the cache...
+	for i := 0; i < 100; i++ {
+		if _, ok := c.Get(2); !ok {
+			t.Fatalf("Get(2) miss during warmup")
+		}
+	}
+	// ...yet it is still sitting in the pool at the stale position its
+	// original, much older clock earned it, unless touching it evicts it
+	// from the pool.
+
+	var evicted []int
+	c.OnEvicted = func(key Key, value interface{}) {
+		evicted = append(evicted, key.(int))
+	}
+
+	// Drain the cache one victim at a time via the same eviction path Add
+	// uses, without adding anything new that would itself be fresher.
+	for c.Len() > 0 {
+		c.removeVictim()
+	}
+
+	if len(evicted) != 5 {
+		t.Fatalf("evicted %v, want all 5 entries", evicted)
+	}
+	if last := evicted[len(evicted)-1]; last != 2 {
+		t.Fatalf("eviction order = %v, want key 2 (just accessed 100 times) evicted last", evicted)
+	}
+}
+
+// TestSampledClockWraparoundDoesNotMisrankVictim guards against comparing
+// raw clock values across a wrap of the 24-bit access clock: an entry
+// touched right as the clock wraps gets a small raw value even though it's
+// the freshest thing in the cache, and must not be picked as the victim
+// ahead of a genuinely older entry sitting just below the wrap.
+func TestSampledClockWraparoundDoesNotMisrankVictim(t *testing.T) {
+	c := NewSampled(10, 5)
+	c.clock = 0xFFFFFE // force the next two ticks to straddle the wrap
+	c.Add("old", 1)    // clock -> 0xFFFFFF
+	c.Add("new", 2)    // clock -> 0: freshest entry, but smallest raw clock
+
+	var evicted []string
+	c.OnEvicted = func(key Key, value interface{}) {
+		evicted = append(evicted, key.(string))
+	}
+	for c.Len() > 0 {
+		c.removeVictim()
+	}
+
+	if len(evicted) != 2 {
+		t.Fatalf("evicted %v, want 2 entries", evicted)
+	}
+	if evicted[0] != "old" {
+		t.Fatalf("eviction order = %v, want \"old\" (idle 1 tick) evicted before \"new\" (idle 0 ticks, just wrapped)", evicted)
+	}
+}
+
+// TestNewWithSizeEvictsByCost exercises byte-bounded eviction: entries are
+// evicted from the back until nbytes fits under maxBytes, and OnEvicted
+// fires for whatever was pushed out.
+func TestNewWithSizeEvictsByCost(t *testing.T) {
+	c := NewWithSize(10, func(key Key, value interface{}) int64 {
+		return int64(value.(int))
+	})
+
+	var evicted []string
+	c.OnEvicted = func(key Key, value interface{}) {
+		evicted = append(evicted, key.(string))
+	}
+
+	c.Add("a", 4) // nbytes: 4
+	c.Add("b", 4) // nbytes: 8
+	c.Add("c", 4) // nbytes: 12 > 10, evicts "a" (oldest) down to 8
+
+	if got, want := c.Bytes(), int64(8); got != want {
+		t.Fatalf("Bytes() = %d, want %d", got, want)
+	}
+	if len(evicted) != 1 || evicted[0] != "a" {
+		t.Fatalf("evicted = %v, want [a]", evicted)
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Fatalf("Get(a) hit, want it evicted")
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Fatalf("Get(b) miss, want it still cached")
+	}
+
+	// Updating an existing key in place re-accounts its cost rather than
+	// treating it as a second entry.
+	c.Add("b", 2) // nbytes: 4(c) + 2(b) = 6
+	if got, want := c.Bytes(), int64(6); got != want {
+		t.Fatalf("Bytes() after in-place update = %d, want %d", got, want)
+	}
+	if len(evicted) != 1 {
+		t.Fatalf("evicted = %v, want no additional eviction from an in-place update", evicted)
+	}
+}
+
+// TestAddWithTTLExpiresLazilyOnGet covers AddWithTTL's lazy expiration: once
+// the TTL has passed, Get stops returning the entry and fires OnExpired
+// rather than OnEvicted.
+func TestAddWithTTLExpiresLazilyOnGet(t *testing.T) {
+	c := New(10)
+
+	var evicted, expired []string
+	c.OnEvicted = func(key Key, value interface{}) {
+		evicted = append(evicted, key.(string))
+	}
+	c.OnExpired = func(key Key, value interface{}) {
+		expired = append(expired, key.(string))
+	}
+
+	c.AddWithTTL("a", 1, 10*time.Millisecond)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatalf("Get(a) miss before TTL elapsed")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if _, ok := c.Get("a"); ok {
+		t.Fatalf("Get(a) hit after TTL elapsed, want (nil, false)")
+	}
+	if len(expired) != 1 || expired[0] != "a" {
+		t.Fatalf("expired = %v, want [a]", expired)
+	}
+	if len(evicted) != 0 {
+		t.Fatalf("evicted = %v, want none: expiry should fire OnExpired, not OnEvicted", evicted)
+	}
+}
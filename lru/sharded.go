@@ -0,0 +1,145 @@
+package lru
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// ShardedCache wraps N independent Cache instances, each guarded by its own
+// Mutex, so unrelated keys don't contend on a single lock. groupcache's
+// outer cache currently serializes every Get/Add on one mutex; spreading
+// keys across shards lets unrelated keys proceed without contending on that
+// one lock, at the cost of giving up a single, globally exact LRU order.
+// Note this buys nothing for concurrent reads of the *same* key: Cache.Get
+// reorders its internal list on every hit, so every access shard-wide needs
+// the exclusive lock regardless of whether it's a Get or an Add.
+// ShardedCache 包装了 N 个相互独立的 Cache，每个分片各自拥有一把 Mutex，
+// 这样不相关的 key 不会争抢同一把锁。groupcache 外层缓存目前所有的
+// Get/Add 都挤在同一把互斥锁上，按 key 分片之后不相关的 key 就不用再互相等待，
+// 代价是不再有一个全局严格精确的 LRU 顺序。注意这对同一个 key 的并发读没有任何
+// 好处：Cache.Get 命中时也会调整内部链表顺序，所以不管是 Get 还是 Add，每次
+// 访问都需要拿到排他锁。
+type ShardedCache struct {
+	shards []*cacheShard
+}
+
+type cacheShard struct {
+	mu    sync.Mutex
+	cache *Cache
+
+	hits, misses, evictions uint64
+}
+
+// ShardStats reports hit/miss/eviction counters for a single shard, so
+// callers can detect hot-shard skew and retune shard count.
+// ShardStats 报告单个分片的命中/未命中/淘汰计数，便于调用方发现热点分片并
+// 调整分片数量。
+type ShardStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+// NewSharded creates a ShardedCache of the given number of shards, each an
+// lru.Cache capped at maxEntriesPerShard entries (see Cache.MaxEntries; zero
+// means unlimited, same as New). shards is clamped to at least 1.
+// NewSharded 创建一个包含 shards 个分片的 ShardedCache，每个分片都是一个
+// 上限为 maxEntriesPerShard 条目的 lru.Cache（语义同 Cache.MaxEntries，为 0
+// 表示不限制，与 New 一致）。shards 至少为 1。
+func NewSharded(shards int, maxEntriesPerShard int) *ShardedCache {
+	if shards <= 0 {
+		shards = 1
+	}
+	sc := &ShardedCache{shards: make([]*cacheShard, shards)}
+	for i := range sc.shards {
+		s := &cacheShard{cache: New(maxEntriesPerShard)}
+		s.cache.OnEvicted = func(Key, interface{}) {
+			// Add already holds s.mu for the duration of the eviction it
+			// triggers, so no additional locking is needed here.
+			s.evictions++
+		}
+		sc.shards[i] = s
+	}
+	return sc
+}
+
+// shardFor returns the shard key is assigned to, via fnv32(key) % N. string
+// and []byte (groupcache's overwhelmingly common key types) are hashed
+// directly; anything else falls back to fmt.Fprint, which is correct for
+// any comparable Key but pays for reflection-based formatting.
+func (sc *ShardedCache) shardFor(key Key) *cacheShard {
+	h := fnv.New32a()
+	switch k := key.(type) {
+	case string:
+		h.Write([]byte(k))
+	case []byte:
+		h.Write(k)
+	default:
+		fmt.Fprint(h, key)
+	}
+	return sc.shards[h.Sum32()%uint32(len(sc.shards))]
+}
+
+// Add adds a value to the cache, in the shard key hashes to.
+func (sc *ShardedCache) Add(key Key, value interface{}) {
+	s := sc.shardFor(key)
+	s.mu.Lock()
+	s.cache.Add(key, value)
+	s.mu.Unlock()
+}
+
+// Get looks up a key's value from the cache, recording a hit or miss
+// against the owning shard's stats.
+func (sc *ShardedCache) Get(key Key) (value interface{}, ok bool) {
+	s := sc.shardFor(key)
+	s.mu.Lock()
+	value, ok = s.cache.Get(key)
+	if ok {
+		s.hits++
+	} else {
+		s.misses++
+	}
+	s.mu.Unlock()
+	return value, ok
+}
+
+// Remove removes the provided key from the cache.
+func (sc *ShardedCache) Remove(key Key) {
+	s := sc.shardFor(key)
+	s.mu.Lock()
+	s.cache.Remove(key)
+	s.mu.Unlock()
+}
+
+// Len returns the total number of items across all shards.
+func (sc *ShardedCache) Len() int {
+	total := 0
+	for _, s := range sc.shards {
+		s.mu.Lock()
+		total += s.cache.Len()
+		s.mu.Unlock()
+	}
+	return total
+}
+
+// Clear purges all stored items from every shard.
+func (sc *ShardedCache) Clear() {
+	for _, s := range sc.shards {
+		s.mu.Lock()
+		s.cache.Clear()
+		s.mu.Unlock()
+	}
+}
+
+// Stats returns a copy of the hit/miss/eviction counters for each shard, in
+// shard order, so callers can spot hot-shard skew and tune shard count.
+func (sc *ShardedCache) Stats() []ShardStats {
+	stats := make([]ShardStats, len(sc.shards))
+	for i, s := range sc.shards {
+		s.mu.Lock()
+		stats[i] = ShardStats{Hits: s.hits, Misses: s.misses, Evictions: s.evictions}
+		s.mu.Unlock()
+	}
+	return stats
+}
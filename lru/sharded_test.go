@@ -0,0 +1,76 @@
+package lru
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestShardedCacheSmoke is a basic sanity check that Add/Get/Remove/Len/
+// Stats all work as expected across shards.
+func TestShardedCacheSmoke(t *testing.T) {
+	sc := NewSharded(4, 10)
+
+	for i := 0; i < 20; i++ {
+		sc.Add(fmt.Sprintf("key-%d", i), i)
+	}
+	if got, want := sc.Len(), 20; got != want {
+		t.Fatalf("Len() = %d, want %d", got, want)
+	}
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if v, ok := sc.Get(key); !ok || v != i {
+			t.Fatalf("Get(%s) = %v, %v, want %d, true", key, v, ok, i)
+		}
+	}
+
+	sc.Remove("key-0")
+	if got, want := sc.Len(), 19; got != want {
+		t.Fatalf("Len() after Remove = %d, want %d", got, want)
+	}
+	if _, ok := sc.Get("key-0"); ok {
+		t.Fatalf("Get(key-0) hit after Remove")
+	}
+
+	var hits, misses uint64
+	for _, s := range sc.Stats() {
+		hits += s.Hits
+		misses += s.Misses
+	}
+	if hits != 20 {
+		t.Fatalf("total hits = %d, want 20", hits)
+	}
+	if misses != 1 {
+		t.Fatalf("total misses = %d, want 1 (the post-Remove Get(key-0))", misses)
+	}
+
+	sc.Clear()
+	if got, want := sc.Len(), 0; got != want {
+		t.Fatalf("Len() after Clear = %d, want %d", got, want)
+	}
+}
+
+// TestShardedCacheConcurrent hammers Add/Get across many goroutines and
+// shards; run with -race to catch per-shard locking races (Get takes the
+// shard's exclusive lock, since Cache.Get reorders its list on every hit).
+func TestShardedCacheConcurrent(t *testing.T) {
+	sc := NewSharded(8, 1000)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 32; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				key := fmt.Sprintf("key-%d", (g*500+i)%200)
+				sc.Add(key, i)
+				sc.Get(key)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if got, want := sc.Len(), 200; got != want {
+		t.Fatalf("Len() = %d, want %d", got, want)
+	}
+}
@@ -18,14 +18,27 @@ limitations under the License.
 // mechanism.
 package singleflight
 
-import "sync"
+import (
+	"context"
+	"sync"
+)
 
-// call is an in-flight or completed Do call
+// call is an in-flight or completed Do/DoContext call
 // 正在进行中，或已经结束的请求
 type call struct {
-	wg  sync.WaitGroup // 等待多个协程完成,避免重入
-	val interface{}    // 请求得到的正常结果
-	err error          // 请求得到的异常结果
+	done chan struct{} // closed when val/err are ready，代替 WaitGroup，支持 select 等待
+	val  interface{}   // 请求得到的正常结果
+	err  error         // 请求得到的异常结果
+
+	// cancel aborts the context driving fn. It is non-nil only for calls
+	// started via DoContext.
+	// cancel 用来终止驱动 fn 的 context，只有通过 DoContext 发起的请求才会设置
+	cancel context.CancelFunc
+
+	// followers is the number of callers (leader included) still waiting
+	// on this call. Guarded by Group.mu.
+	// followers 记录还在等待这次请求结果的调用方数量（含发起者），由 Group.mu 保护
+	followers int
 }
 
 // Group represents a class of work and forms a namespace in which
@@ -53,24 +66,97 @@ func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, err
 	}
 	// 获取到key的执行实例
 	if c, ok := g.m[key]; ok { //如果存在说明这个key有正在请求的call
+		c.followers++
 		// key的执行实例已经拿到了，先把整个Group的锁解开，
 		// 这里没有IO，预计所不会阻塞其他协程操作其他key太久
 		g.mu.Unlock()
-		c.wg.Wait()         // 如果之前已经有人发起了这个缓存的请求正在进行中，则等待
+		<-c.done            // 如果之前已经有人发起了这个缓存的请求正在进行中，则等待
 		return c.val, c.err // 等待完毕就返回别人请求到的缓存结果
 	}
 	// 代码走到这里，说明目前当前没有其他协程，在请求这个缓存
-	c := new(call) // 发起一个请求
-	c.wg.Add(1)    // 准备开始开始工作，这个Group中的其他协程将等待我的请求结果
-	g.m[key] = c   // 注册一下这个key的请求任务
-	g.mu.Unlock()  // m 缓存的请求注册中心，操作完毕，交出锁
+	c := &call{done: make(chan struct{}), followers: 1} // 发起一个请求
+	g.m[key] = c                                        // 注册一下这个key的请求任务
+	g.mu.Unlock()                                       // m 缓存的请求注册中心，操作完毕，交出锁
 
 	c.val, c.err = fn() // 执行key的远端请求任务，io部分
-	c.wg.Done()         // 请求完毕，通知其他协程，可以那我的结果了
+	close(c.done)       // 请求完毕，通知其他协程，可以那我的结果了
 
-	g.mu.Lock()      // 给注册中心上个锁，准备删除掉本次请求
-	delete(g.m, key) //删删删
-	g.mu.Unlock()    // m 缓存的请求注册中心，操作完毕，交出锁
+	g.mu.Lock() // 给注册中心上个锁，准备删除掉本次请求
+	// Only delete our own entry: if Forget ran while we were in flight, a
+	// new call may already be registered under key, and deleting that one
+	// would let a third caller start yet another duplicate fn.
+	// 只删除属于自己的那个entry：如果在请求进行期间 Forget 被调用过，key
+	// 可能已经注册了一个新的 call，这时候误删会让后来者又发起一次重复请求。
+	if g.m[key] == c {
+		delete(g.m, key)
+	}
+	g.mu.Unlock() // m 缓存的请求注册中心，操作完毕，交出锁
 
 	return c.val, c.err // 返回结果
 }
+
+// DoContext is like Do but the leading call's fn runs under a context
+// derived from the first caller's ctx, and each follower waits only as
+// long as its own ctx allows. A follower whose ctx is done returns
+// ctx.Err() without affecting the in-flight call or any other follower.
+// Once every caller (leader and followers alike) has given up, the
+// call's context is canceled so a hung upstream fetch doesn't run forever.
+// DoContext 与 Do 类似，但发起者的 fn 运行在由第一个调用者的 ctx 派生出的 context 下，
+// 每个 follower 只会等待到自己的 ctx 结束为止：某个 follower 的 ctx 到期时，
+// 只返回 ctx.Err()，不会影响正在进行的请求，也不会影响其他 follower。
+// 当所有调用者（发起者和全部 follower）都放弃等待后，才会取消驱动 fn 的 context，
+// 避免一个挂死的上游请求无限期占用资源。
+func (g *Group) DoContext(ctx context.Context, key string, fn func(context.Context) (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	if c, ok := g.m[key]; ok {
+		c.followers++
+		g.mu.Unlock()
+		select {
+		case <-c.done:
+			return c.val, c.err
+		case <-ctx.Done():
+			g.mu.Lock()
+			c.followers--
+			if c.followers == 0 && c.cancel != nil {
+				c.cancel()
+			}
+			g.mu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+
+	callCtx, cancel := context.WithCancel(ctx)
+	c := &call{done: make(chan struct{}), cancel: cancel, followers: 1}
+	g.m[key] = c
+	g.mu.Unlock()
+
+	c.val, c.err = fn(callCtx)
+	close(c.done)
+	cancel() // release resources tied to callCtx regardless of outcome
+
+	g.mu.Lock()
+	// See the matching comment in Do: only remove our own entry, in case
+	// Forget already let a new call take over this key.
+	if g.m[key] == c {
+		delete(g.m, key)
+	}
+	g.mu.Unlock()
+
+	return c.val, c.err
+}
+
+// Forget tells the Group to forget about a key. Future calls to Do (or
+// DoContext) for this key will call fn rather than waiting for an
+// earlier call to complete. It has no effect on an already-waiting
+// caller, which still receives the result of the in-flight call.
+// Forget 让 Group 忘记某个 key，之后对该 key 的 Do/DoContext 调用会重新执行 fn，
+// 而不是等待之前那次请求的结果；已经在等待的调用方不受影响，仍然会拿到那次请求的结果。
+// 常用于请求失败后主动失效，避免污染后续调用（比如防止缓存穿透场景下的负缓存）。
+func (g *Group) Forget(key string) {
+	g.mu.Lock()
+	delete(g.m, key)
+	g.mu.Unlock()
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2012 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package singleflight
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDoContextFollowerTimeoutIndependent(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	leaderDone := make(chan struct{})
+	go func() {
+		g.DoContext(context.Background(), "k", func(ctx context.Context) (interface{}, error) {
+			close(started)
+			<-release
+			return "value", nil
+		})
+		close(leaderDone)
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := g.DoContext(ctx, "k", func(ctx context.Context) (interface{}, error) {
+		t.Fatal("follower should not invoke fn; leader is already in flight")
+		return nil, nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("follower err = %v, want context.DeadlineExceeded", err)
+	}
+
+	close(release)
+	<-leaderDone
+
+	// Once the leader has finished, its entry is gone, so a new call for the
+	// same key runs its own fn.
+	v, err := g.DoContext(context.Background(), "k", func(ctx context.Context) (interface{}, error) {
+		return "second", nil
+	})
+	if err != nil || v != "second" {
+		t.Fatalf("post-timeout Do = %v, %v, want \"second\", nil", v, err)
+	}
+}
+
+func TestForgetDoesNotCauseExtraInvocations(t *testing.T) {
+	var g Group
+	var calls int32
+
+	release1 := make(chan struct{})
+	started1 := make(chan struct{})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		g.Do("k", func() (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			close(started1)
+			<-release1
+			return 1, nil
+		})
+	}()
+	<-started1
+
+	// More followers joining the first, still in-flight call.
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.Do("k", func() (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				return 1, nil
+			})
+		}()
+	}
+	time.Sleep(10 * time.Millisecond) // let the followers register against the first call
+
+	g.Forget("k")
+
+	release2 := make(chan struct{})
+	started2 := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		g.Do("k", func() (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			close(started2)
+			<-release2
+			return 2, nil
+		})
+	}()
+	<-started2
+
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.Do("k", func() (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				return 2, nil
+			})
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	close(release1)
+	close(release2)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Fatalf("fn invoked %d times, want 2 (one pre-Forget call, one shared post-Forget call)", got)
+	}
+}